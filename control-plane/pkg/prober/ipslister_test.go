@@ -0,0 +1,116 @@
+/*
+ * Copyright 2021 The Knative Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package prober
+
+import (
+	"sort"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+)
+
+type fakeEndpointsLister struct {
+	corev1listers.EndpointsLister
+	endpoints map[string]*corev1.Endpoints
+}
+
+func (f fakeEndpointsLister) Endpoints(namespace string) corev1listers.EndpointsNamespaceLister {
+	return fakeEndpointsNamespaceLister{namespace: namespace, endpoints: f.endpoints}
+}
+
+type fakeEndpointsNamespaceLister struct {
+	corev1listers.EndpointsNamespaceLister
+	namespace string
+	endpoints map[string]*corev1.Endpoints
+}
+
+func (f fakeEndpointsNamespaceLister) Get(name string) (*corev1.Endpoints, error) {
+	ep, ok := f.endpoints[f.namespace+"/"+name]
+	if !ok {
+		return nil, apierrors.NewNotFound(corev1.Resource("endpoints"), name)
+	}
+	return ep, nil
+}
+
+func TestIPsListerFromEndpoints(t *testing.T) {
+	svc := types.NamespacedName{Namespace: "ns", Name: "svc"}
+
+	tests := []struct {
+		name      string
+		endpoints map[string]*corev1.Endpoints
+		wantIPs   []string
+		wantErr   bool
+	}{{
+		name:      "service has no endpoints object",
+		endpoints: map[string]*corev1.Endpoints{},
+		wantErr:   true,
+	}, {
+		name: "single subset, multiple ready addresses",
+		endpoints: map[string]*corev1.Endpoints{
+			"ns/svc": {
+				Subsets: []corev1.EndpointSubset{{
+					Addresses: []corev1.EndpointAddress{{IP: "10.0.0.1"}, {IP: "10.0.0.2"}},
+				}},
+			},
+		},
+		wantIPs: []string{"10.0.0.1", "10.0.0.2"},
+	}, {
+		name: "multiple subsets are all collected",
+		endpoints: map[string]*corev1.Endpoints{
+			"ns/svc": {
+				Subsets: []corev1.EndpointSubset{
+					{Addresses: []corev1.EndpointAddress{{IP: "10.0.0.1"}}},
+					{Addresses: []corev1.EndpointAddress{{IP: "10.0.0.2"}}},
+				},
+			},
+		},
+		wantIPs: []string{"10.0.0.1", "10.0.0.2"},
+	}, {
+		name: "no ready addresses yields no IPs",
+		endpoints: map[string]*corev1.Endpoints{
+			"ns/svc": {Subsets: []corev1.EndpointSubset{{}}},
+		},
+		wantIPs: nil,
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lister := IPsListerFromEndpoints(svc, fakeEndpointsLister{endpoints: tt.endpoints})
+
+			ips, err := lister()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			sort.Strings(ips)
+			if len(ips) != len(tt.wantIPs) {
+				t.Fatalf("got IPs %v, want %v", ips, tt.wantIPs)
+			}
+			for i := range ips {
+				if ips[i] != tt.wantIPs[i] {
+					t.Fatalf("got IPs %v, want %v", ips, tt.wantIPs)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,230 @@
+/*
+ * Copyright 2021 The Knative Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package prober
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/Shopify/sarama"
+	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// KafkaClientFunc creates a sarama.Client connected to the given bootstrap
+// servers. It's a seam so tests can stub out the Kafka connection.
+type KafkaClientFunc func(bootstrapServers []string) (sarama.Client, error)
+
+// kafkaConnection is a Kafka client and the cluster admin built on top of
+// it, kept open and reused across probes instead of being dialed fresh
+// (including the SASL/TLS handshake) on every poll tick.
+type kafkaConnection struct {
+	client sarama.Client
+	admin  sarama.ClusterAdmin
+}
+
+func (c *kafkaConnection) Close() {
+	c.admin.Close()
+	c.client.Close()
+}
+
+// ConsumerGroupProber is a Prober that reports StatusReady only once a
+// Trigger/Subscription's consumer group has actually joined and committed
+// offsets that have caught up with the high watermark captured when the
+// resource was first probed. This closes the event-loss window where a
+// Trigger is marked Ready before its consumer group exists, so events
+// produced in that window are silently skipped.
+//
+// The Addressable passed to Probe must have GroupID, Topic and
+// BootstrapServers set.
+type ConsumerGroupProber struct {
+	logger *zap.Logger
+
+	newClient KafkaClientFunc
+
+	// connMu guards connections.
+	connMu sync.Mutex
+	// connections caches one kafkaConnection per distinct bootstrap server
+	// list, keyed on the servers in the order given, so the many Triggers/Subscriptions sharing a cluster share a
+	// single connection across the repeated Probe calls a readiness
+	// poll/retry loop makes.
+	connections map[string]*kafkaConnection
+
+	// mu guards highWatermarks.
+	mu sync.Mutex
+	// highWatermarks caches, per resource key, the high watermark of each
+	// partition observed the first time the resource was probed. The
+	// consumer group only needs to catch up to these watermarks, not
+	// whatever the high watermark happens to be by the time it's probed
+	// again, since further production after programming must not block
+	// readiness.
+	highWatermarks map[types.NamespacedName]map[int32]int64
+}
+
+// NewConsumerGroupProber creates a new ConsumerGroupProber.
+func NewConsumerGroupProber(logger *zap.Logger, newClient KafkaClientFunc) *ConsumerGroupProber {
+	return &ConsumerGroupProber{
+		logger:         logger,
+		newClient:      newClient,
+		connections:    make(map[string]*kafkaConnection),
+		highWatermarks: make(map[types.NamespacedName]map[int32]int64),
+	}
+}
+
+// Evict removes the cached reference high watermarks for key, if any. It
+// must be called from the Trigger/Subscription's delete handler once the
+// resource is gone, otherwise highWatermarks grows by one entry per
+// ever-seen resource for the lifetime of the process.
+func (p *ConsumerGroupProber) Evict(key types.NamespacedName) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	delete(p.highWatermarks, key)
+}
+
+// Close closes every cached Kafka connection.
+func (p *ConsumerGroupProber) Close() {
+	p.connMu.Lock()
+	defer p.connMu.Unlock()
+
+	for key, conn := range p.connections {
+		conn.Close()
+		delete(p.connections, key)
+	}
+}
+
+// Probe implements Prober.
+func (p *ConsumerGroupProber) Probe(ctx context.Context, addressable Addressable, expected Status) Status {
+	if addressable.GroupID == "" || addressable.Topic == "" {
+		p.logger.Error("ConsumerGroupProber requires GroupID and Topic to be set")
+		return StatusUnknown
+	}
+
+	conn, err := p.connection(addressable.BootstrapServers)
+	if err != nil {
+		p.logger.Error("Failed to get Kafka connection", zap.Error(err))
+		return StatusUnknown
+	}
+
+	highWatermarks, err := p.referenceHighWatermarks(conn.client, addressable)
+	if err != nil {
+		p.logger.Error("Failed to get topic high watermarks", zap.Error(err))
+		return StatusUnknown
+	}
+
+	partitions := make([]int32, 0, len(highWatermarks))
+	for partition := range highWatermarks {
+		partitions = append(partitions, partition)
+	}
+
+	offsets, err := conn.admin.ListConsumerGroupOffsets(addressable.GroupID, map[string][]int32{addressable.Topic: partitions})
+	if err != nil {
+		p.logger.Error("Failed to list consumer group offsets", zap.Error(err))
+		return StatusUnknown
+	}
+
+	block, ok := offsets.Blocks[addressable.Topic]
+	if !ok {
+		return StatusNotReady
+	}
+
+	if !highWatermarksCaughtUp(highWatermarks, block) {
+		return StatusNotReady
+	}
+
+	return StatusReady
+}
+
+// connection returns the cached kafkaConnection for bootstrapServers,
+// dialing and caching one on the first call.
+func (p *ConsumerGroupProber) connection(bootstrapServers []string) (*kafkaConnection, error) {
+	key := strings.Join(bootstrapServers, ",")
+
+	p.connMu.Lock()
+	defer p.connMu.Unlock()
+
+	if conn, ok := p.connections[key]; ok {
+		return conn, nil
+	}
+
+	client, err := p.newClient(bootstrapServers)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Kafka client: %w", err)
+	}
+
+	admin, err := sarama.NewClusterAdminFromClient(client)
+	if err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to create Kafka cluster admin: %w", err)
+	}
+
+	conn := &kafkaConnection{client: client, admin: admin}
+	p.connections[key] = conn
+	return conn, nil
+}
+
+// highWatermarksCaughtUp reports whether block has a committed offset for
+// every partition in highWatermarks that is at least that partition's
+// reference high watermark.
+func highWatermarksCaughtUp(highWatermarks map[int32]int64, block map[int32]*sarama.OffsetFetchResponseBlock) bool {
+	for partition, highWatermark := range highWatermarks {
+		offsetBlock, ok := block[partition]
+		if !ok || offsetBlock.Offset < highWatermark {
+			return false
+		}
+	}
+	return true
+}
+
+// referenceHighWatermarks returns the cached reference high watermarks for
+// the given resource, querying and caching them on the first call. The
+// Kafka I/O happens without holding mu, so a burst of first probes for
+// different resources queries Kafka concurrently instead of being
+// serialized process-wide; at most a redundant query is done if two probes
+// for the same resource race to populate the cache.
+func (p *ConsumerGroupProber) referenceHighWatermarks(client sarama.Client, addressable Addressable) (map[int32]int64, error) {
+	p.mu.Lock()
+	hw, ok := p.highWatermarks[addressable.ResourceKey]
+	p.mu.Unlock()
+	if ok {
+		return hw, nil
+	}
+
+	partitions, err := client.Partitions(addressable.Topic)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get partitions for topic %s: %w", addressable.Topic, err)
+	}
+
+	hw = make(map[int32]int64, len(partitions))
+	for _, partition := range partitions {
+		offset, err := client.GetOffset(addressable.Topic, partition, sarama.OffsetNewest)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get high watermark for %s/%d: %w", addressable.Topic, partition, err)
+		}
+		hw[partition] = offset
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if existing, ok := p.highWatermarks[addressable.ResourceKey]; ok {
+		return existing, nil
+	}
+	p.highWatermarks[addressable.ResourceKey] = hw
+	return hw, nil
+}
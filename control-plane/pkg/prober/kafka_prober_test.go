@@ -0,0 +1,165 @@
+/*
+ * Copyright 2021 The Knative Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package prober
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// fakeSaramaClient implements just the sarama.Client methods
+// referenceHighWatermarks needs.
+type fakeSaramaClient struct {
+	sarama.Client
+	partitions map[string][]int32
+	offsets    map[string]map[int32]int64
+}
+
+func (f fakeSaramaClient) Partitions(topic string) ([]int32, error) {
+	return f.partitions[topic], nil
+}
+
+func (f fakeSaramaClient) GetOffset(topic string, partition int32, _ int64) (int64, error) {
+	return f.offsets[topic][partition], nil
+}
+
+func TestHighWatermarksCaughtUp(t *testing.T) {
+	tests := []struct {
+		name           string
+		highWatermarks map[int32]int64
+		block          map[int32]*sarama.OffsetFetchResponseBlock
+		want           bool
+	}{{
+		name:           "no partitions to check",
+		highWatermarks: map[int32]int64{},
+		block:          map[int32]*sarama.OffsetFetchResponseBlock{},
+		want:           true,
+	}, {
+		name:           "every partition caught up",
+		highWatermarks: map[int32]int64{0: 10, 1: 20},
+		block: map[int32]*sarama.OffsetFetchResponseBlock{
+			0: {Offset: 10},
+			1: {Offset: 25},
+		},
+		want: true,
+	}, {
+		name:           "a partition is behind",
+		highWatermarks: map[int32]int64{0: 10, 1: 20},
+		block: map[int32]*sarama.OffsetFetchResponseBlock{
+			0: {Offset: 10},
+			1: {Offset: 19},
+		},
+		want: false,
+	}, {
+		name:           "a partition has no committed offset at all",
+		highWatermarks: map[int32]int64{0: 10, 1: 20},
+		block: map[int32]*sarama.OffsetFetchResponseBlock{
+			0: {Offset: 10},
+		},
+		want: false,
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := highWatermarksCaughtUp(tt.highWatermarks, tt.block); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReferenceHighWatermarksCachesPerResource(t *testing.T) {
+	client := fakeSaramaClient{
+		partitions: map[string][]int32{"my-topic": {0, 1}},
+		offsets:    map[string]map[int32]int64{"my-topic": {0: 10, 1: 20}},
+	}
+	addressable := Addressable{ResourceKey: types.NamespacedName{Namespace: "ns", Name: "trigger"}, Topic: "my-topic"}
+
+	p := NewConsumerGroupProber(zap.NewNop(), nil)
+
+	got, err := p.referenceHighWatermarks(client, addressable)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := map[int32]int64{0: 10, 1: 20}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	// A subsequent call with a client that would return different
+	// watermarks must still return the cached, original values.
+	changedClient := fakeSaramaClient{
+		partitions: map[string][]int32{"my-topic": {0, 1}},
+		offsets:    map[string]map[int32]int64{"my-topic": {0: 999, 1: 999}},
+	}
+	got, err = p.referenceHighWatermarks(changedClient, addressable)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got[0] != 10 || got[1] != 20 {
+		t.Fatalf("expected cached watermarks to stick, got %v", got)
+	}
+
+	p.Evict(addressable.ResourceKey)
+	got, err = p.referenceHighWatermarks(changedClient, addressable)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got[0] != 999 || got[1] != 999 {
+		t.Fatalf("expected watermarks to be re-queried after Evict, got %v", got)
+	}
+}
+
+func TestAndProberProbe(t *testing.T) {
+	ready := Func(func(ctx context.Context, addressable Addressable, expected Status) Status { return StatusReady })
+	notReady := Func(func(ctx context.Context, addressable Addressable, expected Status) Status { return StatusNotReady })
+	unknown := Func(func(ctx context.Context, addressable Addressable, expected Status) Status { return StatusUnknown })
+
+	tests := []struct {
+		name   string
+		probes AndProber
+		want   Status
+	}{{
+		name:   "empty",
+		probes: AndProber{},
+		want:   StatusReady,
+	}, {
+		name:   "all ready",
+		probes: AndProber{ready, ready},
+		want:   StatusReady,
+	}, {
+		name:   "one not ready",
+		probes: AndProber{ready, notReady},
+		want:   StatusNotReady,
+	}, {
+		name:   "one unknown short-circuits to unknown",
+		probes: AndProber{notReady, unknown, ready},
+		want:   StatusUnknown,
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.probes.Probe(context.Background(), Addressable{}, StatusReady); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -24,6 +24,7 @@ import (
 
 	"go.uber.org/zap"
 	"k8s.io/apimachinery/pkg/types"
+	corev1listers "k8s.io/client-go/listers/core/v1"
 	"knative.dev/pkg/network"
 )
 
@@ -33,8 +34,34 @@ type Addressable struct {
 	Address *url.URL
 	// Resource key.
 	ResourceKey types.NamespacedName
+	// ExpectedHash is the config hash the data-plane Pod backing this
+	// Addressable is expected to have already applied (for instance the
+	// generation of the broker/trigger ConfigMap). A probe only reports
+	// StatusReady when the Pod echoes this same hash back.
+	ExpectedHash string
+
+	// GroupID, Topic and BootstrapServers identify the Kafka consumer
+	// group backing this Addressable. They are only consulted by a
+	// ConsumerGroupProber.
+	GroupID          string
+	Topic            string
+	BootstrapServers []string
+
+	// ProbeTarget, when set, scopes the probe to a single
+	// Trigger/Subscription rather than the whole dispatcher. It's sent as
+	// the probeTargetHeaderName header and is expected to be a sub-resource
+	// path of the form "/probe/{namespace}/{name}/{uid}". The data-plane
+	// receiver replies 200 once it has registered a consumer for that UID
+	// with the expected config hash, 425 while it's still joining, and 404
+	// once it has been removed.
+	ProbeTarget string
 }
 
+// probeTargetHeaderName is the header carrying Addressable.ProbeTarget, so
+// the data-plane receiver knows which Trigger/Subscription to report on
+// instead of the readiness of the whole dispatcher.
+const probeTargetHeaderName = "K-Probe-Target"
+
 // EnqueueFunc enqueues the given provided resource key.
 type EnqueueFunc func(key types.NamespacedName)
 
@@ -44,6 +71,27 @@ type Prober interface {
 	Probe(ctx context.Context, addressable Addressable, expected Status) Status
 }
 
+// AndProber probes an Addressable against every Prober it wraps and reports
+// StatusReady only when all of them agree. This composes, for instance, HTTP
+// readiness and Kafka consumer-group readiness into a single check.
+type AndProber []Prober
+
+// Probe implements Prober.
+func (ps AndProber) Probe(ctx context.Context, addressable Addressable, expected Status) Status {
+	status := StatusReady
+	for _, p := range ps {
+		switch s := p.Probe(ctx, addressable, expected); s {
+		case StatusReady:
+			continue
+		case StatusNotReady:
+			status = StatusNotReady
+		default:
+			return StatusUnknown
+		}
+	}
+	return status
+}
+
 // Func type is an adapter to allow the use of
 // ordinary functions as Prober. If f is a function
 // with the appropriate signature, Func(f) is a
@@ -60,7 +108,7 @@ type httpClient interface {
 	Do(r *http.Request) (*http.Response, error)
 }
 
-func probe(ctx context.Context, client httpClient, logger *zap.Logger, address string) Status {
+func probe(ctx context.Context, client httpClient, logger *zap.Logger, address string, expectedHash string, probeTarget string) Status {
 	logger.Debug("Sending probe request")
 
 	r, err := http.NewRequestWithContext(ctx, http.MethodGet, address, nil)
@@ -69,7 +117,10 @@ func probe(ctx context.Context, client httpClient, logger *zap.Logger, address s
 		return StatusUnknown
 	}
 	r.Header.Add(network.ProbeHeaderName, network.ProbeHeaderValue)
-	r.Header.Add(network.HashHeaderName, "probe")
+	r.Header.Add(network.HashHeaderName, expectedHash)
+	if probeTarget != "" {
+		r.Header.Add(probeTargetHeaderName, probeTarget)
+	}
 
 	select {
 	case <-ctx.Done():
@@ -83,11 +134,28 @@ func probe(ctx context.Context, client httpClient, logger *zap.Logger, address s
 		return StatusUnknown
 	}
 
-	if response.StatusCode != http.StatusOK {
+	switch response.StatusCode {
+	case http.StatusOK:
+		// Fall through to the config hash check below.
+	case http.StatusTooEarly:
+		logger.Info("Resource still joining", zap.Int("statusCode", response.StatusCode))
+		return StatusNotReady
+	case http.StatusNotFound:
+		logger.Info("Resource not found", zap.Int("statusCode", response.StatusCode))
+		return StatusUnknown
+	default:
 		logger.Info("Resource not ready", zap.Int("statusCode", response.StatusCode))
 		return StatusNotReady
 	}
 
+	if gotHash := response.Header.Get(network.HashHeaderName); gotHash != expectedHash {
+		logger.Info("Resource config hash mismatch",
+			zap.String("want", expectedHash),
+			zap.String("got", gotHash),
+		)
+		return StatusNotReady
+	}
+
 	return StatusReady
 }
 
@@ -96,3 +164,25 @@ func IPsListerFromService(svc types.NamespacedName) IPsLister {
 		return []string{fmt.Sprintf("%s.%s.svc", svc.Name, svc.Namespace)}, nil
 	}
 }
+
+// IPsListerFromEndpoints returns an IPsLister that resolves the given Service
+// to the IPs of its ready backing Pods through an Endpoints informer, rather
+// than the single DNS name returned by IPsListerFromService. This lets the
+// prober reach, and verify the config hash of, every data-plane replica
+// individually instead of whichever replica the Service happens to route to.
+func IPsListerFromEndpoints(svc types.NamespacedName, lister corev1listers.EndpointsLister) IPsLister {
+	return func() ([]string, error) {
+		endpoints, err := lister.Endpoints(svc.Namespace).Get(svc.Name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get endpoints %s/%s: %w", svc.Namespace, svc.Name, err)
+		}
+
+		var ips []string
+		for _, subset := range endpoints.Subsets {
+			for _, address := range subset.Addresses {
+				ips = append(ips, address.IP)
+			}
+		}
+		return ips, nil
+	}
+}
@@ -0,0 +1,90 @@
+/*
+ * Copyright 2021 The Knative Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package prober
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"go.uber.org/zap"
+	"knative.dev/pkg/network"
+)
+
+type fakeHTTPClient struct {
+	response *http.Response
+	err      error
+}
+
+func (f fakeHTTPClient) Do(r *http.Request) (*http.Response, error) {
+	return f.response, f.err
+}
+
+func newResponse(statusCode int, hash string) *http.Response {
+	header := http.Header{}
+	if hash != "" {
+		header.Set(network.HashHeaderName, hash)
+	}
+	return &http.Response{StatusCode: statusCode, Header: header}
+}
+
+func TestProbe(t *testing.T) {
+	tests := []struct {
+		name         string
+		response     *http.Response
+		expectedHash string
+		probeTarget  string
+		want         Status
+	}{{
+		name:         "ready: matching hash",
+		response:     newResponse(http.StatusOK, "abc"),
+		expectedHash: "abc",
+		want:         StatusReady,
+	}, {
+		name:         "not ready: hash mismatch",
+		response:     newResponse(http.StatusOK, "old"),
+		expectedHash: "new",
+		want:         StatusNotReady,
+	}, {
+		name:         "not ready: too early while consumer is joining",
+		response:     newResponse(http.StatusTooEarly, ""),
+		expectedHash: "abc",
+		probeTarget:  "/probe/ns/name/uid",
+		want:         StatusNotReady,
+	}, {
+		name:         "unknown: sub-resource no longer registered",
+		response:     newResponse(http.StatusNotFound, ""),
+		expectedHash: "abc",
+		probeTarget:  "/probe/ns/name/uid",
+		want:         StatusUnknown,
+	}, {
+		name:         "not ready: any other non-200 status",
+		response:     newResponse(http.StatusServiceUnavailable, ""),
+		expectedHash: "abc",
+		want:         StatusNotReady,
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := fakeHTTPClient{response: tt.response}
+			got := probe(context.Background(), client, zap.NewNop(), "http://example.com", tt.expectedHash, tt.probeTarget)
+			if got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+/*
+ * Copyright 2021 The Knative Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package testlib
+
+import (
+	"testing"
+
+	"k8s.io/client-go/kubernetes"
+)
+
+// Client bundles the state every test/lib helper needs: the *testing.T to
+// fail on, a Kubernetes clientset, the namespace under test, and a Tracker
+// that helpers register their created resources with for cleanup.
+//
+// This mirrors the fields of the knative eventing *testlib.Client that the
+// eventing-kafka e2e Must* helpers take (MustPublishKafkaMessage,
+// MustCreateKafkaUserForTopic, ...), so new helpers should accept a *Client
+// rather than inventing their own ad hoc parameter list.
+type Client struct {
+	T         *testing.T
+	Kube      kubernetes.Interface
+	Namespace string
+	Tracker   *Tracker
+}
+
+// Tracker records cleanup functions for resources created through a Client
+// and runs them in LIFO order, typically from a t.Cleanup callback.
+type Tracker struct {
+	cleanups []func()
+}
+
+// Add registers cleanup to run when the Tracker is cleaned.
+func (tr *Tracker) Add(cleanup func()) {
+	tr.cleanups = append(tr.cleanups, cleanup)
+}
+
+// Clean runs every registered cleanup, most recently added first.
+func (tr *Tracker) Clean() {
+	for i := len(tr.cleanups) - 1; i >= 0; i-- {
+		tr.cleanups[i]()
+	}
+	tr.cleanups = nil
+}
@@ -0,0 +1,118 @@
+/*
+ * Copyright 2021 The Knative Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package testlib
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+)
+
+func newTestEvent(t *testing.T) cloudevents.Event {
+	t.Helper()
+	event := cloudevents.NewEvent()
+	event.SetID("id-1")
+	event.SetSource("source")
+	event.SetType("type")
+	event.SetSubject("subject-1")
+	event.SetTime(time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC))
+	event.SetExtension("traceparent", "00-abc-def-01")
+	if err := event.SetData(cloudevents.ApplicationJSON, map[string]string{"hello": "world"}); err != nil {
+		t.Fatalf("failed to set event data: %v", err)
+	}
+	return event
+}
+
+func headerValue(headers []sarama.RecordHeader, key string) (string, bool) {
+	for _, h := range headers {
+		if string(h.Key) == key {
+			return string(h.Value), true
+		}
+	}
+	return "", false
+}
+
+func TestCeBinaryHeaders(t *testing.T) {
+	event := newTestEvent(t)
+
+	headers := ceBinaryHeaders(event)
+
+	for key, want := range map[string]string{
+		"ce_id":          "id-1",
+		"ce_source":      "source",
+		"ce_type":        "type",
+		"ce_specversion": event.SpecVersion(),
+		"ce_subject":     "subject-1",
+		"ce_time":        "2021-01-02T03:04:05Z",
+		"ce_traceparent": "00-abc-def-01",
+		"content-type":   cloudevents.ApplicationJSON,
+	} {
+		got, ok := headerValue(headers, key)
+		if !ok {
+			t.Errorf("missing header %q", key)
+			continue
+		}
+		if got != want {
+			t.Errorf("header %q = %q, want %q", key, got, want)
+		}
+	}
+}
+
+func TestToKafkaMessage(t *testing.T) {
+	event := newTestEvent(t)
+
+	t.Run("binary", func(t *testing.T) {
+		message, err := toKafkaMessage("my-topic", event, BinaryCloudEvent)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if message.Topic != "my-topic" {
+			t.Errorf("got topic %q, want %q", message.Topic, "my-topic")
+		}
+		if key, _ := message.Key.Encode(); string(key) != "subject-1" {
+			t.Errorf("got key %q, want %q", key, "subject-1")
+		}
+		if _, ok := headerValue(message.Headers, "ce_id"); !ok {
+			t.Errorf("binary message missing ce_id header")
+		}
+	})
+
+	t.Run("structured", func(t *testing.T) {
+		message, err := toKafkaMessage("my-topic", event, StructuredCloudEvent)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if ct, ok := headerValue(message.Headers, "content-type"); !ok || ct != "application/cloudevents+json" {
+			t.Errorf("got content-type header %q, ok=%v", ct, ok)
+		}
+		value, err := message.Value.Encode()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(value) == 0 {
+			t.Errorf("structured message value is empty")
+		}
+	})
+
+	t.Run("unknown encoding", func(t *testing.T) {
+		if _, err := toKafkaMessage("my-topic", event, CloudEventEncoding(99)); err == nil {
+			t.Errorf("expected an error for an unknown encoding")
+		}
+	})
+}
@@ -0,0 +1,114 @@
+/*
+ * Copyright 2021 The Knative Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package testlib
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+// WaitForConsumerGroupCaughtUp polls bootstrapServer, authenticating as the
+// Strimzi KafkaUser named user the same way MustPublishCloudEvent does,
+// until consumer group groupID has a committed offset on every partition of
+// topic that is at least that partition's high watermark, or ctx expires.
+//
+// CheckKafkaSourceState and WaitForKafkaResourceReady only assert that a
+// Trigger/Subscription reports Ready; they don't prove that events produced
+// before it became Ready were actually delivered. This helper lets
+// integration tests assert a true end-to-end drain, which is the only
+// reliable way to catch the event-loss regressions that have historically
+// hit the consolidated channel during subscription programming. Because it
+// authenticates the same way MustPublishCloudEvent does, the two compose in
+// the same auth-path e2e test.
+func WaitForConsumerGroupCaughtUp(ctx context.Context, client *Client, bootstrapServer, topic, user, groupID string) error {
+	client.T.Helper()
+
+	secret := mustGetKafkaUserSecret(client, user)
+
+	config, err := newAuthenticatedSaramaConfig(user, secret)
+	if err != nil {
+		return fmt.Errorf("failed to build Kafka client config for user %s: %w", user, err)
+	}
+
+	kafkaClient, err := sarama.NewClient([]string{bootstrapServer}, config)
+	if err != nil {
+		return fmt.Errorf("failed to create Kafka client: %w", err)
+	}
+	defer kafkaClient.Close()
+
+	admin, err := sarama.NewClusterAdminFromClient(kafkaClient)
+	if err != nil {
+		return fmt.Errorf("failed to create Kafka cluster admin: %w", err)
+	}
+	defer admin.Close()
+
+	partitions, err := kafkaClient.Partitions(topic)
+	if err != nil {
+		return fmt.Errorf("failed to get partitions for topic %s: %w", topic, err)
+	}
+
+	ticker := time.NewTicker(2 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		caughtUp, err := isConsumerGroupCaughtUp(kafkaClient, admin, groupID, topic, partitions)
+		if err != nil {
+			return err
+		}
+		if caughtUp {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("consumer group %s did not catch up on topic %s before context expired: %w", groupID, topic, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// isConsumerGroupCaughtUp reports whether groupID has committed an offset
+// for every given partition of topic that is at least that partition's
+// current high watermark.
+func isConsumerGroupCaughtUp(client sarama.Client, admin sarama.ClusterAdmin, groupID, topic string, partitions []int32) (bool, error) {
+	offsets, err := admin.ListConsumerGroupOffsets(groupID, map[string][]int32{topic: partitions})
+	if err != nil {
+		return false, fmt.Errorf("failed to list consumer group offsets for %s: %w", groupID, err)
+	}
+
+	block, ok := offsets.Blocks[topic]
+	if !ok {
+		return false, nil
+	}
+
+	for _, partition := range partitions {
+		highWatermark, err := client.GetOffset(topic, partition, sarama.OffsetNewest)
+		if err != nil {
+			return false, fmt.Errorf("failed to get high watermark for %s/%d: %w", topic, partition, err)
+		}
+
+		offsetBlock, ok := block[partition]
+		if !ok || offsetBlock.Offset < highWatermark {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
@@ -0,0 +1,90 @@
+/*
+ * Copyright 2021 The Knative Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package testlib
+
+import (
+	"testing"
+
+	"github.com/Shopify/sarama"
+)
+
+type fakeClient struct {
+	sarama.Client
+	offsets map[int32]int64
+}
+
+func (f fakeClient) GetOffset(_ string, partition int32, _ int64) (int64, error) {
+	return f.offsets[partition], nil
+}
+
+type fakeClusterAdmin struct {
+	sarama.ClusterAdmin
+	blocks map[int32]*sarama.OffsetFetchResponseBlock
+}
+
+func (f fakeClusterAdmin) ListConsumerGroupOffsets(_ string, _ map[string][]int32) (*sarama.OffsetFetchResponse, error) {
+	return &sarama.OffsetFetchResponse{Blocks: map[string]map[int32]*sarama.OffsetFetchResponseBlock{"my-topic": f.blocks}}, nil
+}
+
+func TestIsConsumerGroupCaughtUp(t *testing.T) {
+	tests := []struct {
+		name       string
+		offsets    map[int32]int64
+		blocks     map[int32]*sarama.OffsetFetchResponseBlock
+		partitions []int32
+		want       bool
+	}{{
+		name:       "caught up on every partition",
+		offsets:    map[int32]int64{0: 10, 1: 20},
+		blocks:     map[int32]*sarama.OffsetFetchResponseBlock{0: {Offset: 10}, 1: {Offset: 20}},
+		partitions: []int32{0, 1},
+		want:       true,
+	}, {
+		name:       "ahead of the reference high watermark",
+		offsets:    map[int32]int64{0: 10},
+		blocks:     map[int32]*sarama.OffsetFetchResponseBlock{0: {Offset: 15}},
+		partitions: []int32{0},
+		want:       true,
+	}, {
+		name:       "behind on one partition",
+		offsets:    map[int32]int64{0: 10, 1: 20},
+		blocks:     map[int32]*sarama.OffsetFetchResponseBlock{0: {Offset: 10}, 1: {Offset: 5}},
+		partitions: []int32{0, 1},
+		want:       false,
+	}, {
+		name:       "group hasn't committed on this topic at all",
+		offsets:    map[int32]int64{0: 10},
+		blocks:     nil,
+		partitions: []int32{0},
+		want:       false,
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := fakeClient{offsets: tt.offsets}
+			admin := fakeClusterAdmin{blocks: tt.blocks}
+
+			got, err := isConsumerGroupCaughtUp(client, admin, "my-group", "my-topic", tt.partitions)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}